@@ -1,15 +1,25 @@
 package seo
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,20 +38,707 @@ j=d.createElement(s),dl=l!='dataLayer'?'&l='+l:'';j.async=true;j.src=
 <noscript><iframe src="https://www.googletagmanager.com/ns.html?id=%s"
 height="0" width="0" style="display:none;visibility:hidden"></iframe></noscript>
 <!-- End Google Tag Manager (noscript) -->`
+
+	// maxURLsPerSitemap and maxSitemapBytes mirror the limits published at
+	// sitemaps.org; once either is exceeded a single <urlset> is replaced
+	// by a sitemap index pointing at gzipped shards.
+	maxURLsPerSitemap = 50000
+	maxSitemapBytes   = 50 * 1024 * 1024
+
+	sitemapNSImage = "http://www.google.com/schemas/sitemap-image/1.1"
+	sitemapNSVideo = "http://www.google.com/schemas/sitemap-video/1.1"
+	sitemapNSNews  = "http://www.google.com/schemas/sitemap-news/0.9"
+	sitemapNSXhtml = "http://www.w3.org/1999/xhtml"
+)
+
+// Regexes used to pull sitemap-relevant assets out of the HTML bodies
+// modifyingWriter already buffers. They are deliberately tolerant of
+// attribute order and quoting rather than a full HTML parse.
+var (
+	imgSrcRe        = regexp.MustCompile(`(?i)<img\b[^>]*\bsrc=["']([^"']+)["']`)
+	videoBlockRe    = regexp.MustCompile(`(?is)<video\b([^>]*)>(.*?)</video>`)
+	videoPosterRe   = regexp.MustCompile(`(?i)\bposter=["']([^"']+)["']`)
+	videoSrcAttrRe  = regexp.MustCompile(`(?i)\bsrc=["']([^"']+)["']`)
+	linkAlternateRe = regexp.MustCompile(`(?i)<link\b[^>]*\brel=["']alternate["'][^>]*>`)
+	hreflangAttrRe  = regexp.MustCompile(`(?i)\bhreflang=["']([^"']+)["']`)
+	hrefAttrRe      = regexp.MustCompile(`(?i)\bhref=["']([^"']+)["']`)
+	publishedTimeRe = regexp.MustCompile(`(?i)<meta\b[^>]*\bproperty=["']article:published_time["'][^>]*\bcontent=["']([^"']+)["']`)
+	anchorHrefRe    = regexp.MustCompile(`(?i)<a\b[^>]*\bhref=["']([^"']+)["']`)
+	ogSiteNameRe    = regexp.MustCompile(`(?i)<meta\b[^>]*\bproperty=["']og:site_name["'][^>]*\bcontent=["']([^"']+)["']`)
+	titleTagRe      = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlLangRe      = regexp.MustCompile(`(?i)<html\b[^>]*\blang=["']([^"']+)["']`)
 )
 
 type Config struct {
-	SitemapPath string   `json:"sitemapPath,omitempty"`
-	RobotsPath  string   `json:"robotsPath,omitempty"`
-	Ignore      []string `json:"ignore,omitempty"`
-	GTMID       string   `json:"gtmID,omitempty"`
+	SitemapPath      string       `json:"sitemapPath,omitempty"`
+	SitemapIndexPath string       `json:"sitemapIndexPath,omitempty"`
+	ShardPathPrefix  string       `json:"shardPathPrefix,omitempty"`
+	PerHostShards    bool         `json:"perHostShards,omitempty"`
+	RobotsPath       string       `json:"robotsPath,omitempty"`
+	Ignore           []string     `json:"ignore,omitempty"`
+	GTMID            string       `json:"gtmID,omitempty"`
+	StoreType        string       `json:"storeType,omitempty"` // "", "json", "bolt", "redis"
+	StorePath        string       `json:"storePath,omitempty"` // file path for the json/bolt stores
+	RedisAddr        string       `json:"redisAddr,omitempty"`
+	RedisKey         string       `json:"redisKey,omitempty"`
+	PathTTL          string       `json:"pathTTL,omitempty"` // e.g. "720h"; empty disables pruning
+	Robots           RobotsConfig `json:"robots,omitempty"`
+	Minify           MinifyConfig `json:"minify,omitempty"`
+	DevMode          bool         `json:"devMode,omitempty"`
+	SeedURLs         []string     `json:"seedURLs,omitempty"`
+	SeedHosts        []string     `json:"seedHosts,omitempty"` // allow-list; defaults to the hosts of SeedURLs
+	SeedConcurrency  int          `json:"seedConcurrency,omitempty"`
+	SeedRatePerSec   float64      `json:"seedRatePerSec,omitempty"`
+	SeedMaxDepth     int          `json:"seedMaxDepth,omitempty"`
+	SeedMaxURLs      int          `json:"seedMaxURLs,omitempty"`
+}
+
+const sseEventsPath = "/__sitemap/events"
+
+const devReloadScriptTemplate = `<script>
+(function(){
+	var es = new EventSource('%s');
+	es.onmessage = function(){ location.reload(); };
+	es.onerror = function(){ es.close(); };
+})();
+</script>`
+
+// sseHub fans out newly-recorded-URL events to every open /__sitemap/events
+// connection; it only exists when DevMode is enabled.
+type sseHub struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+func newSSEHub() *sseHub {
+	return &sseHub{subs: make(map[chan []byte]struct{})}
+}
+
+func (h *sseHub) subscribe() chan []byte {
+	ch := make(chan []byte, 16)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *sseHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+	close(ch)
+}
+
+func (h *sseHub) broadcast(event []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default: // a slow subscriber misses an event rather than blocking the request
+		}
+	}
+}
+
+// MinifyConfig turns on the output minification stage. With Enabled set but
+// no ContentTypes given, defaultMinifyContentTypes is used.
+type MinifyConfig struct {
+	Enabled      bool     `json:"enabled,omitempty"`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+}
+
+// defaultMinifyContentTypes includes text/plain so that robots.txt - served
+// with that content-type - is minified along with the sitemap/HTML output
+// whenever minification is turned on, not silently skipped.
+var defaultMinifyContentTypes = []string{
+	"text/html",
+	"application/xml",
+	"text/css",
+	"application/javascript",
+	"application/json",
+	"image/svg+xml",
+	"text/plain",
+}
+
+// Minifier rewrites a response body for a given content-type, behind an
+// interface so the stdMinifier implementation can be swapped out in tests or
+// for a different minifier entirely.
+type Minifier interface {
+	Minify(contentType string, b []byte) ([]byte, error)
+}
+
+// stdMinifier implements Minifier with small, conservative, stdlib-only
+// passes. It used to wrap github.com/tdewolff/minify/v2, but Traefik loads
+// plugins through Yaegi, which can only interpret a plugin's own vendored
+// source tree - and the pinned tdewolff release also requires a newer Go
+// than this repo targets. These passes are deliberately conservative (strip
+// comments and blank/trailing whitespace, nothing that requires parsing the
+// content) rather than byte-perfect, since a regex-based pass has no safe
+// way to tell a JS string literal or a <pre> block from ordinary markup.
+type stdMinifier struct{}
+
+func newStdMinifier() *stdMinifier {
+	return &stdMinifier{}
+}
+
+var (
+	htmlCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	cssCommentRe  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	trailingWSRe  = regexp.MustCompile(`[ \t]+\n`)
+	blankLinesRe  = regexp.MustCompile(`\n{3,}`)
+)
+
+// stripBlankAndTrailing trims trailing whitespace from every line and
+// collapses runs of blank lines to one, without touching any line's content -
+// safe even for formats like JS/JSON where a content-aware pass risks
+// corrupting a string literal.
+func stripBlankAndTrailing(b []byte) []byte {
+	s := trailingWSRe.ReplaceAllString(string(b), "\n")
+	s = blankLinesRe.ReplaceAllString(s, "\n\n")
+	return []byte(strings.TrimSpace(s) + "\n")
+}
+
+// minifyMarkup strips comments matched by commentRe, then applies
+// stripBlankAndTrailing. It deliberately doesn't collapse inter-tag
+// whitespace: that's only safe outside whitespace-significant elements like
+// <pre>/<textarea>, which a regex-based pass can't reliably detect.
+func minifyMarkup(b []byte, commentRe *regexp.Regexp) []byte {
+	return stripBlankAndTrailing(commentRe.ReplaceAll(b, nil))
+}
+
+func (t *stdMinifier) Minify(contentType string, b []byte) ([]byte, error) {
+	ct := baseContentType(contentType)
+	switch {
+	case ct == "text/html" || ct == "image/svg+xml" || strings.HasSuffix(ct, "/xml") || strings.HasSuffix(ct, "+xml"):
+		return minifyMarkup(b, htmlCommentRe), nil
+	case ct == "text/css":
+		return minifyMarkup(b, cssCommentRe), nil
+	case ct == "application/javascript" || ct == "text/plain" || strings.HasSuffix(ct, "/json") || strings.HasSuffix(ct, "+json"):
+		return stripBlankAndTrailing(b), nil
+	default:
+		return nil, fmt.Errorf("stdMinifier: unsupported content type %q", contentType)
+	}
+}
+
+func baseContentType(contentType string) string {
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// RobotsGroup is a single User-agent block in robots.txt.
+type RobotsGroup struct {
+	UserAgents []string `json:"userAgents,omitempty"`
+	Allow      []string `json:"allow,omitempty"`
+	Disallow   []string `json:"disallow,omitempty"`
+	CrawlDelay int      `json:"crawlDelay,omitempty"`
+	Host       string   `json:"host,omitempty"`
+}
+
+// RobotsConfig drives buildRobotsTxt. With no groups configured, a single
+// "User-agent: *" block with no rules is emitted, followed by a blank line
+// and the Sitemap line(s).
+type RobotsConfig struct {
+	Groups       []RobotsGroup `json:"groups,omitempty"`
+	SitemapURLs  []string      `json:"sitemapURLs,omitempty"`
+	AutoDisallow bool          `json:"autoDisallow,omitempty"`
+	FragmentsDir string        `json:"fragmentsDir,omitempty"` // concatenated robots.txt.d/ fragments
 }
 
 func CreateConfig() *Config {
 	return &Config{
-		SitemapPath: "/sitemap.xml",
-		RobotsPath:  "/robots.txt",
+		SitemapPath:      "/sitemap.xml",
+		SitemapIndexPath: "/sitemap_index.xml",
+		ShardPathPrefix:  "/sitemaps",
+		RobotsPath:       "/robots.txt",
+	}
+}
+
+// PathStore persists the full recorded per-URL metadata (lastmod, discovered
+// assets, hreflang alternates, news fields) across restarts so a freshly
+// started instance doesn't serve an empty - or metadata-stripped - sitemap
+// until traffic rebuilds it.
+type PathStore interface {
+	Load(ctx context.Context) (map[string]*pathInfo, error)
+	Save(ctx context.Context, paths map[string]*pathInfo) error
+	Record(ctx context.Context, url string, info *pathInfo) error
+}
+
+// storedPathInfo is the JSON-serializable mirror of pathInfo. pathInfo's
+// fields are unexported like the rest of sitemapGenerator's internal state,
+// so stores round-trip through this type instead of marshaling pathInfo
+// directly.
+type storedPathInfo struct {
+	Loc           string            `json:"loc"`
+	FirstSeen     time.Time         `json:"firstSeen"`
+	LastMod       time.Time         `json:"lastmod"`
+	UpdateCount   int               `json:"updateCount,omitempty"`
+	Images        []string          `json:"images,omitempty"`
+	Videos        []storedVideo     `json:"videos,omitempty"`
+	Hreflangs     map[string]string `json:"hreflangs,omitempty"`
+	NewsPublished string            `json:"newsPublished,omitempty"`
+	NewsName      string            `json:"newsName,omitempty"`
+	NewsLanguage  string            `json:"newsLanguage,omitempty"`
+	NewsTitle     string            `json:"newsTitle,omitempty"`
+}
+
+type storedVideo struct {
+	ContentLoc   string `json:"contentLoc"`
+	ThumbnailLoc string `json:"thumbnailLoc,omitempty"`
+}
+
+// newStoredPathInfo snapshots info into its serializable form. The result
+// shares no mutable state with info, so it's safe to use after info's owning
+// sg.mu has been released.
+func newStoredPathInfo(info *pathInfo) storedPathInfo {
+	var images []string
+	for img := range info.images {
+		images = append(images, img)
+	}
+	sort.Strings(images)
+
+	var videos []storedVideo
+	for _, v := range info.videos {
+		videos = append(videos, storedVideo{ContentLoc: v.contentLoc, ThumbnailLoc: v.thumbnailLoc})
+	}
+
+	hreflangs := make(map[string]string, len(info.hreflangs))
+	for lang, href := range info.hreflangs {
+		hreflangs[lang] = href
+	}
+
+	return storedPathInfo{
+		Loc:           info.loc,
+		FirstSeen:     info.firstSeen,
+		LastMod:       info.lastmod,
+		UpdateCount:   info.updateCount,
+		Images:        images,
+		Videos:        videos,
+		Hreflangs:     hreflangs,
+		NewsPublished: info.newsPublished,
+		NewsName:      info.newsName,
+		NewsLanguage:  info.newsLanguage,
+		NewsTitle:     info.newsTitle,
+	}
+}
+
+func (s storedPathInfo) toPathInfo() *pathInfo {
+	info := &pathInfo{
+		loc:           s.Loc,
+		firstSeen:     s.FirstSeen,
+		lastmod:       s.LastMod,
+		updateCount:   s.UpdateCount,
+		hreflangs:     s.Hreflangs,
+		newsPublished: s.NewsPublished,
+		newsName:      s.NewsName,
+		newsLanguage:  s.NewsLanguage,
+		newsTitle:     s.NewsTitle,
+	}
+	if len(s.Images) > 0 {
+		info.images = make(map[string]struct{}, len(s.Images))
+		for _, img := range s.Images {
+			info.images[img] = struct{}{}
+		}
+	}
+	for _, v := range s.Videos {
+		info.videos = append(info.videos, videoAsset{contentLoc: v.ContentLoc, thumbnailLoc: v.ThumbnailLoc})
+	}
+	return info
+}
+
+func newPathStore(config *Config) (PathStore, error) {
+	switch config.StoreType {
+	case "json":
+		if config.StorePath == "" {
+			return nil, fmt.Errorf("storePath is required for the json store")
+		}
+		return &jsonPathStore{path: config.StorePath}, nil
+	case "bolt":
+		if config.StorePath == "" {
+			return nil, fmt.Errorf("storePath is required for the bolt store")
+		}
+		return newBoltPathStore(config.StorePath)
+	case "redis":
+		if config.RedisAddr == "" {
+			return nil, fmt.Errorf("redisAddr is required for the redis store")
+		}
+		key := config.RedisKey
+		if key == "" {
+			key = "sitemap:paths"
+		}
+		return &redisPathStore{addr: config.RedisAddr, key: key}, nil
+	default:
+		return nil, fmt.Errorf("unknown storeType %q", config.StoreType)
+	}
+}
+
+type jsonPathStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *jsonPathStore) Load(ctx context.Context) (map[string]*pathInfo, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]*pathInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stored map[string]storedPathInfo
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]*pathInfo, len(stored))
+	for url, s := range stored {
+		paths[url] = s.toPathInfo()
+	}
+	return paths, nil
+}
+
+func (s *jsonPathStore) Save(ctx context.Context, paths map[string]*pathInfo) error {
+	stored := make(map[string]storedPathInfo, len(paths))
+	for url, info := range paths {
+		stored[url] = newStoredPathInfo(info)
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *jsonPathStore) Record(ctx context.Context, url string, info *pathInfo) error {
+	// The on-disk JSON file is only ever rewritten wholesale by Save - on the
+	// periodic tick, or after every recorded request in DevMode - so Record
+	// itself writes nothing.
+	return nil
+}
+
+// boltPathStoreEntry is the unit persisted to the append-only log file:
+// Record appends one of these per call, and Load replays the log keeping the
+// last entry seen per URL.
+type boltPathStoreEntry struct {
+	URL  string         `json:"url"`
+	Info storedPathInfo `json:"info"`
+}
+
+// boltPathStore persists paths as a length-prefixed-JSON append-only log
+// file, using only the standard library. It used to be backed by
+// go.etcd.io/bbolt, but Traefik loads plugins through Yaegi, which can only
+// interpret a plugin's own vendored source - and bbolt's mmap/unsafe-based
+// file access in particular doesn't interpret at all. The append-only log
+// keeps the property that made "bolt" worth choosing over "json" in the
+// first place: Record doesn't rewrite the whole file on every request.
+// Save, run on the periodic tick (or inline in DevMode), compacts the log
+// down to one entry per URL.
+type boltPathStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func newBoltPathStore(path string) (*boltPathStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt store: %v", err)
+	}
+	return &boltPathStore{path: path, f: f}, nil
+}
+
+func (s *boltPathStore) Load(ctx context.Context) (map[string]*pathInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	defer s.f.Seek(0, io.SeekEnd)
+
+	stored := make(map[string]storedPathInfo)
+	r := bufio.NewReader(s.f)
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			break // EOF, or a short trailing write from a crash mid-append.
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		var entry boltPathStoreEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		stored[entry.URL] = entry.Info
+	}
+
+	paths := make(map[string]*pathInfo, len(stored))
+	for url, s := range stored {
+		paths[url] = s.toPathInfo()
+	}
+	return paths, nil
+}
+
+func (s *boltPathStore) appendEntry(url string, info storedPathInfo) error {
+	data, err := json.Marshal(boltPathStoreEntry{URL: url, Info: info})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := binary.Write(s.f, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = s.f.Write(data)
+	return err
+}
+
+func (s *boltPathStore) Record(ctx context.Context, url string, info *pathInfo) error {
+	return s.appendEntry(url, newStoredPathInfo(info))
+}
+
+// Save compacts the log down to exactly one entry per URL - the only point
+// the whole file is rewritten, matching jsonPathStore's periodic-tick
+// tradeoff - via the same write-tmp-then-rename pattern jsonPathStore uses.
+func (s *boltPathStore) Save(ctx context.Context, paths map[string]*pathInfo) error {
+	tmp := s.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	for url, info := range paths {
+		data, err := json.Marshal(boltPathStoreEntry{URL: url, Info: newStoredPathInfo(info)})
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+		if err := binary.Write(tmpFile, binary.BigEndian, uint32(len(data))); err != nil {
+			tmpFile.Close()
+			return err
+		}
+		if _, err := tmpFile.Write(data); err != nil {
+			tmpFile.Close()
+			return err
+		}
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	return nil
+}
+
+// respValue is a parsed RESP (Redis Serialization Protocol) reply: exactly
+// the subset (simple strings, integers, bulk strings, arrays) needed to
+// speak HGETALL/HSET.
+type respValue struct {
+	str   string
+	num   int64
+	arr   []*respValue
+	isNil bool
+}
+
+func readRESP(r *bufio.Reader) (*respValue, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return &respValue{str: line[1:]}, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &respValue{num: n}, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return &respValue{isNil: true}, nil
+		}
+		data := make([]byte, n+2) // payload plus the trailing CRLF
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return &respValue{str: string(data[:n])}, nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return &respValue{isNil: true}, nil
+		}
+		arr := make([]*respValue, n)
+		for i := range arr {
+			v, err := readRESP(r)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return &respValue{arr: arr}, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+	}
+}
+
+// redisPathStore speaks just enough RESP over a plain net.Conn to drive
+// HGETALL/HSET against a hash, using only the standard library. It used to
+// wrap github.com/redis/go-redis/v9, but Yaegi - the interpreter Traefik
+// loads plugins with - can only interpret a plugin's own vendored source
+// tree, and a client this small doesn't need a full dependency to replace.
+type redisPathStore struct {
+	addr string
+	key  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *redisPathStore) connect() (net.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// do sends a RESP command array and returns the parsed reply, retrying once
+// against a fresh connection if the cached one turns out to be dead (e.g.
+// closed by the server after sitting idle).
+func (s *redisPathStore) do(args ...string) (*respValue, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	val, err := s.doOnce(conn, args)
+	if err == nil {
+		return val, nil
+	}
+
+	s.mu.Lock()
+	if s.conn == conn {
+		conn.Close()
+		s.conn = nil
+	}
+	s.mu.Unlock()
+
+	conn, err = s.connect()
+	if err != nil {
+		return nil, err
+	}
+	return s.doOnce(conn, args)
+}
+
+func (s *redisPathStore) doOnce(conn net.Conn, args []string) (*respValue, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return readRESP(bufio.NewReader(conn))
+}
+
+func (s *redisPathStore) Load(ctx context.Context) (map[string]*pathInfo, error) {
+	reply, err := s.do("HGETALL", s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]*pathInfo)
+	for i := 0; i+1 < len(reply.arr); i += 2 {
+		var stored storedPathInfo
+		if err := json.Unmarshal([]byte(reply.arr[i+1].str), &stored); err != nil {
+			continue
+		}
+		paths[reply.arr[i].str] = stored.toPathInfo()
 	}
+	return paths, nil
+}
+
+func (s *redisPathStore) Save(ctx context.Context, paths map[string]*pathInfo) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, 2+2*len(paths))
+	args = append(args, "HSET", s.key)
+	for url, info := range paths {
+		data, err := json.Marshal(newStoredPathInfo(info))
+		if err != nil {
+			return err
+		}
+		args = append(args, url, string(data))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *redisPathStore) Record(ctx context.Context, url string, info *pathInfo) error {
+	data, err := json.Marshal(newStoredPathInfo(info))
+	if err != nil {
+		return err
+	}
+	_, err = s.do("HSET", s.key, url, string(data))
+	return err
 }
 
 type statusWriter struct {
@@ -85,20 +782,38 @@ func (w *modifyingWriter) WriteHeader(code int) {
 }
 
 type sitemapGenerator struct {
-	next        http.Handler
-	name        string
-	sitemapPath string
-	robotsPath  string
-	ignores     []*regexp.Regexp
-	paths       map[string]struct{}
-	gtmID       string
-	mu          sync.Mutex
+	next             http.Handler
+	name             string
+	sitemapPath      string
+	sitemapIndexPath string
+	shardPathPrefix  string
+	perHostShards    bool
+	shardRe          *regexp.Regexp
+	robotsPath       string
+	robots           RobotsConfig
+	ignores          []*regexp.Regexp
+	ignorePatterns   []string
+	paths            map[string]*pathInfo
+	gtmID            string
+	store            PathStore
+	pathTTL          time.Duration
+	minify           MinifyConfig
+	minifier         Minifier
+	devMode          bool
+	sse              *sseHub
+	mu               sync.Mutex
 }
 
 func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
 	if config.SitemapPath == "" {
 		config.SitemapPath = "/sitemap.xml"
 	}
+	if config.SitemapIndexPath == "" {
+		config.SitemapIndexPath = "/sitemap_index.xml"
+	}
+	if config.ShardPathPrefix == "" {
+		config.ShardPathPrefix = "/sitemaps"
+	}
 	if config.RobotsPath == "" {
 		config.RobotsPath = "/robots.txt"
 	}
@@ -137,71 +852,239 @@ func New(ctx context.Context, next http.Handler, config *Config, name string) (h
 		ignores = append(ignores, re)
 	}
 
-	sg := &sitemapGenerator{
-		next:        next,
-		name:        name,
-		sitemapPath: config.SitemapPath,
-		robotsPath:  config.RobotsPath,
-		ignores:     ignores,
-		paths:       make(map[string]struct{}),
-		gtmID:       config.GTMID,
-	}
-
-	return sg, nil
-}
+	ignorePatterns := make([]string, 0, len(config.Ignore)+len(defaultPatterns))
+	ignorePatterns = append(ignorePatterns, config.Ignore...)
+	ignorePatterns = append(ignorePatterns, defaultPatterns...)
 
-func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
+	shardPattern := "^" + regexp.QuoteMeta(config.ShardPathPrefix)
+	if config.PerHostShards {
+		shardPattern += `/([^/]+)/sitemap-(\d+)\.xml\.gz$`
+	} else {
+		shardPattern += `/sitemap-(\d+)\.xml\.gz$`
+	}
 
-	if path == sg.sitemapPath {
-		xmlContent := sg.buildSitemapXML(req)
-		if xmlContent == nil {
-			http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
-			return
+	var pathTTL time.Duration
+	if config.PathTTL != "" {
+		parsed, err := time.ParseDuration(config.PathTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pathTTL %s: %v", config.PathTTL, err)
 		}
-		rw.Header().Set("Content-Type", "application/xml")
-		rw.WriteHeader(http.StatusOK)
-		rw.Write(xmlContent)
-		return
+		pathTTL = parsed
 	}
 
-	if path == sg.robotsPath {
-		robotsContent := sg.buildRobotsTxt(req)
-		rw.Header().Set("Content-Type", "text/plain")
-		rw.WriteHeader(http.StatusOK)
-		rw.Write([]byte(robotsContent))
-		return
+	sg := &sitemapGenerator{
+		next:             next,
+		name:             name,
+		sitemapPath:      config.SitemapPath,
+		sitemapIndexPath: config.SitemapIndexPath,
+		shardPathPrefix:  config.ShardPathPrefix,
+		perHostShards:    config.PerHostShards,
+		shardRe:          regexp.MustCompile(shardPattern),
+		robotsPath:       config.RobotsPath,
+		robots:           config.Robots,
+		ignores:          ignores,
+		ignorePatterns:   ignorePatterns,
+		paths:            make(map[string]*pathInfo),
+		gtmID:            config.GTMID,
+		pathTTL:          pathTTL,
+		minify:           config.Minify,
+		devMode:          config.DevMode,
 	}
 
-	ignored := false
-	for _, re := range sg.ignores {
-		if re.MatchString(path) {
-			ignored = true
-			break
+	if sg.devMode {
+		sg.sse = newSSEHub()
+	}
+
+	if sg.minify.Enabled {
+		if len(sg.minify.ContentTypes) == 0 {
+			sg.minify.ContentTypes = defaultMinifyContentTypes
 		}
+		sg.minifier = newStdMinifier()
 	}
 
-	scheme := req.Header.Get("X-Forwarded-Proto")
-	if scheme == "" {
-		scheme = req.URL.Scheme
+	if config.StoreType != "" {
+		store, err := newPathStore(config)
+		if err != nil {
+			return nil, err
+		}
+		sg.store = store
+
+		loaded, err := store.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading persisted paths: %v", err)
+		}
+		for url, info := range loaded {
+			sg.paths[url] = info
+		}
+
+		// In DevMode the store is saved after every recorded request (see
+		// ServeHTTP), so the 1-minute ticker would only add staleness during
+		// iteration.
+		if !sg.devMode {
+			go sg.persistPeriodically()
+		}
 	}
-	host := req.Host
-	fullURL := scheme + "://" + host + strings.TrimSuffix(path, "/")
 
-	mw := &modifyingWriter{
-		ResponseWriter: rw,
-		body:           bytes.NewBuffer([]byte{}),
+	// Pruning runs independently of persistence and of DevMode: pathTTL is a
+	// documented standalone feature (drop routes the upstream site no longer
+	// serves), and "iterating locally with stale-route cleanup on" is a
+	// reasonable combination that shouldn't silently disable pruning.
+	if sg.pathTTL > 0 {
+		go sg.prunePeriodically()
 	}
-	sg.next.ServeHTTP(mw, req)
 
-	if mw.status == 0 {
-		mw.status = http.StatusOK
+	if len(config.SeedURLs) > 0 {
+		go sg.crawlSeeds(context.Background(), config)
 	}
 
-	contentType := rw.Header().Get("Content-Type")
-	contentEncoding := rw.Header().Get("Content-Encoding")
+	return sg, nil
+}
 
-	var bodyBytes []byte = mw.body.Bytes()
+func (sg *sitemapGenerator) persistPeriodically() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		sg.persist()
+	}
+}
+
+func (sg *sitemapGenerator) prunePeriodically() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		sg.pruneStalePaths()
+	}
+}
+
+// pruneStalePaths drops URLs that haven't been seen in pathTTL, so routes
+// that disappear from the upstream site eventually drop out of the sitemap.
+func (sg *sitemapGenerator) pruneStalePaths() {
+	if sg.pathTTL <= 0 {
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-sg.pathTTL)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	for url, info := range sg.paths {
+		if info.lastmod.Before(cutoff) {
+			delete(sg.paths, url)
+		}
+	}
+}
+
+func (sg *sitemapGenerator) persist() {
+	if sg.store == nil {
+		return
+	}
+
+	sg.mu.Lock()
+	snapshot := make(map[string]*pathInfo, len(sg.paths))
+	for url, info := range sg.paths {
+		// Snapshot via the serializable form while still under the lock, so
+		// Save (which runs after we unlock) never touches the maps/slices
+		// backing the live pathInfo.
+		snapshot[url] = newStoredPathInfo(info).toPathInfo()
+	}
+	sg.mu.Unlock()
+
+	sg.store.Save(context.Background(), snapshot)
+}
+
+func schemeOf(req *http.Request) string {
+	if scheme := req.Header.Get("X-Forwarded-Proto"); scheme != "" {
+		return scheme
+	}
+	return req.URL.Scheme
+}
+
+func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+
+	if sg.devMode && path == sseEventsPath {
+		sg.serveSSE(rw, req)
+		return
+	}
+
+	if path == sg.sitemapIndexPath {
+		xmlContent := sg.buildSitemapIndexXML(req)
+		if xmlContent == nil {
+			http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/xml")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(sg.maybeMinify("application/xml", xmlContent))
+		return
+	}
+
+	if m := sg.shardRe.FindStringSubmatch(path); m != nil {
+		sg.serveShard(rw, req, m)
+		return
+	}
+
+	if path == sg.sitemapPath {
+		// Scoped to this host, not sg.paths as a whole: in PerHostShards mode
+		// one busy host pushing the global count past the limit shouldn't
+		// force every other (possibly tiny) host onto the index+shard path.
+		base := schemeOf(req) + "://" + req.Host
+		shards := sg.shardsForHost(sg.infosForHost(base))
+
+		var xmlContent []byte
+		if len(shards) > 1 {
+			xmlContent = sg.buildSitemapIndexXML(req)
+		} else {
+			xmlContent = sg.buildSitemapXML(req)
+		}
+		if xmlContent == nil {
+			http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/xml")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(sg.maybeMinify("application/xml", xmlContent))
+		return
+	}
+
+	if path == sg.robotsPath {
+		robotsContent := []byte(sg.buildRobotsTxt(req))
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(sg.maybeMinify("text/plain", robotsContent))
+		return
+	}
+
+	ignored := false
+	for _, re := range sg.ignores {
+		if re.MatchString(path) {
+			ignored = true
+			break
+		}
+	}
+
+	scheme := schemeOf(req)
+	host := req.Host
+	fullURL := scheme + "://" + host + strings.TrimSuffix(path, "/")
+
+	mw := &modifyingWriter{
+		ResponseWriter: rw,
+		body:           bytes.NewBuffer([]byte{}),
+	}
+	sg.next.ServeHTTP(mw, req)
+
+	if mw.status == 0 {
+		mw.status = http.StatusOK
+	}
+
+	contentType := rw.Header().Get("Content-Type")
+	contentEncoding := rw.Header().Get("Content-Encoding")
+
+	var bodyBytes []byte = mw.body.Bytes()
 	var isGzipped bool = strings.EqualFold(contentEncoding, "gzip")
 
 	if isGzipped {
@@ -222,6 +1105,7 @@ func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 	}
 
 	bodyStr := string(bodyBytes)
+	transformed := false
 
 	if sg.gtmID != "" && strings.HasPrefix(strings.ToLower(contentType), "text/html") && mw.status == http.StatusOK {
 		gtmScript := fmt.Sprintf(gtmScriptTemplate, sg.gtmID)
@@ -237,7 +1121,26 @@ func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 		}
 
 		bodyBytes = []byte(modified)
+		transformed = true
+	}
+
+	if sg.devMode && strings.HasPrefix(strings.ToLower(contentType), "text/html") && mw.status == http.StatusOK {
+		reloadScript := fmt.Sprintf(devReloadScriptTemplate, sseEventsPath)
+		bodyBytes = []byte(strings.Replace(string(bodyBytes), "</body>", reloadScript+"</body>", 1))
+		transformed = true
+	}
+
+	// Minification is opt-in and only applied to successful, whole (non-partial)
+	// responses whose content-type was configured; anything else passes through.
+	encodingSafe := contentEncoding == "" || isGzipped
+	if sg.minifier != nil && encodingSafe && mw.status >= 200 && mw.status < 300 && mw.status != http.StatusPartialContent && sg.shouldMinify(contentType) {
+		if minified, err := sg.minifier.Minify(contentType, bodyBytes); err == nil {
+			bodyBytes = minified
+			transformed = true
+		}
+	}
 
+	if transformed {
 		if isGzipped {
 			var gzippedBuf bytes.Buffer
 			writer := gzip.NewWriter(&gzippedBuf)
@@ -260,76 +1163,372 @@ func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 	}
 
 	if !ignored && mw.status == http.StatusOK {
-		sg.mu.Lock()
-		sg.paths[fullURL] = struct{}{}
-		sg.mu.Unlock()
+		info, isNew := sg.record(fullURL, contentType, bodyStr)
+		if sg.store != nil {
+			if sg.devMode {
+				sg.persist()
+			} else {
+				sg.store.Record(req.Context(), fullURL, info)
+			}
+		}
+		if sg.devMode && isNew {
+			sg.publishSSEEvent(fullURL, info.lastmod)
+		}
 	}
 }
 
+// publishSSEEvent notifies every open /__sitemap/events connection that a
+// new URL was added to the sitemap, so dev-mode clients can reload.
+func (sg *sitemapGenerator) publishSSEEvent(url string, seen time.Time) {
+	payload, err := json.Marshal(map[string]string{
+		"url":  url,
+		"time": seen.Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+	sg.sse.broadcast(payload)
+}
+
+func (sg *sitemapGenerator) serveSSE(rw http.ResponseWriter, req *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := sg.sse.subscribe()
+	defer sg.sse.unsubscribe(ch)
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(rw, "data: %s\n\n", event)
+			flusher.Flush()
+		}
+	}
+}
+
+type videoAsset struct {
+	contentLoc   string
+	thumbnailLoc string
+}
+
 type pathInfo struct {
-	loc string
+	loc           string
+	firstSeen     time.Time
+	lastmod       time.Time
+	updateCount   int
+	bodyHash      [32]byte
+	images        map[string]struct{}
+	videos        []videoAsset
+	hreflangs     map[string]string // lang -> href
+	newsPublished string
+	newsName      string
+	newsLanguage  string
+	newsTitle     string
 }
 
-func (sg *sitemapGenerator) buildSitemapXML(req *http.Request) []byte {
+// record stores or refreshes the metadata for a recorded URL. updateCount and
+// lastmod only move when the body's content actually changed since the last
+// recorded hit - otherwise estimateChangefreq would measure how often a URL
+// is *requested* rather than how often it changes, which is what it's
+// supposed to report. The returned pathInfo is a snapshot clone, safe for the
+// caller to hand to a PathStore or SSE publish without holding sg.mu.
+func (sg *sitemapGenerator) record(fullURL, contentType, body string) (info *pathInfo, isNew bool) {
 	sg.mu.Lock()
-	infos := make([]pathInfo, 0, len(sg.paths))
-	for p := range sg.paths {
-		infos = append(infos, pathInfo{loc: p})
+	defer sg.mu.Unlock()
+
+	live, ok := sg.paths[fullURL]
+	if !ok {
+		live = &pathInfo{loc: fullURL, firstSeen: time.Now().UTC()}
+		sg.paths[fullURL] = live
+		isNew = true
 	}
-	sg.mu.Unlock()
 
-	var filteredInfos []pathInfo
-	var base string
-	if req != nil {
-		scheme := req.Header.Get("X-Forwarded-Proto")
-		if scheme == "" {
-			scheme = req.URL.Scheme
-		}
-		base = scheme + "://" + req.Host
-		hasRoot := false
-		for _, info := range infos {
-			if strings.HasPrefix(info.loc, base+"/") || info.loc == base {
-				if info.loc == base {
-					hasRoot = true
-				}
-				filteredInfos = append(filteredInfos, info)
+	hash := sha256.Sum256([]byte(body))
+	if isNew || hash != live.bodyHash {
+		live.bodyHash = hash
+		live.updateCount++
+		live.lastmod = time.Now().UTC()
+
+		if strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+			sg.scanAssets(live, body)
+		}
+	}
+
+	return newStoredPathInfo(live).toPathInfo(), isNew
+}
+
+func (sg *sitemapGenerator) scanAssets(info *pathInfo, body string) {
+	for _, m := range imgSrcRe.FindAllStringSubmatch(body, -1) {
+		if info.images == nil {
+			info.images = make(map[string]struct{})
+		}
+		info.images[m[1]] = struct{}{}
+	}
+
+	for _, block := range videoBlockRe.FindAllStringSubmatch(body, -1) {
+		attrs, inner := block[1], block[2]
+		thumbnail := ""
+		if m := videoPosterRe.FindStringSubmatch(attrs); m != nil {
+			thumbnail = m[1]
+		}
+		for _, m := range videoSrcAttrRe.FindAllStringSubmatch(attrs+inner, -1) {
+			info.videos = appendVideoIfNew(info.videos, videoAsset{contentLoc: m[1], thumbnailLoc: thumbnail})
+		}
+	}
+
+	for _, tag := range linkAlternateRe.FindAllString(body, -1) {
+		lang := hreflangAttrRe.FindStringSubmatch(tag)
+		href := hrefAttrRe.FindStringSubmatch(tag)
+		if lang != nil && href != nil {
+			if info.hreflangs == nil {
+				info.hreflangs = make(map[string]string)
 			}
+			info.hreflangs[lang[1]] = href[1]
 		}
-		if !hasRoot {
-			filteredInfos = append(filteredInfos, pathInfo{loc: base})
+	}
+
+	if m := publishedTimeRe.FindStringSubmatch(body); m != nil {
+		info.newsPublished = m[1]
+	}
+	if m := ogSiteNameRe.FindStringSubmatch(body); m != nil {
+		info.newsName = m[1]
+	}
+	if m := titleTagRe.FindStringSubmatch(body); m != nil {
+		info.newsTitle = strings.TrimSpace(m[1])
+	}
+	if m := htmlLangRe.FindStringSubmatch(body); m != nil {
+		info.newsLanguage = m[1]
+	}
+}
+
+func appendVideoIfNew(videos []videoAsset, asset videoAsset) []videoAsset {
+	for _, v := range videos {
+		if v.contentLoc == asset.contentLoc {
+			return videos
 		}
-	} else {
-		filteredInfos = infos
 	}
+	return append(videos, asset)
+}
+
+// estimateChangefreq derives a sitemap changefreq value from how often a
+// URL's content has actually changed, rather than a constant guess.
+func estimateChangefreq(info *pathInfo) string {
+	if info.updateCount <= 1 {
+		return "monthly"
+	}
+
+	elapsed := info.lastmod.Sub(info.firstSeen)
+	if elapsed <= 0 {
+		return "hourly"
+	}
+
+	avgInterval := elapsed / time.Duration(info.updateCount)
+	switch {
+	case avgInterval < time.Hour:
+		return "hourly"
+	case avgInterval < 24*time.Hour:
+		return "daily"
+	case avgInterval < 7*24*time.Hour:
+		return "weekly"
+	case avgInterval < 30*24*time.Hour:
+		return "monthly"
+	default:
+		return "yearly"
+	}
+}
+
+// infosForHost returns the recorded URLs that belong under base (scheme://host),
+// sorted for stable output, synthesizing a root entry if none was ever recorded.
+func (sg *sitemapGenerator) infosForHost(base string) []*pathInfo {
+	sg.mu.Lock()
+	infos := make([]*pathInfo, 0, len(sg.paths))
+	for _, info := range sg.paths {
+		infos = append(infos, info)
+	}
+	sg.mu.Unlock()
 
-	sort.Slice(filteredInfos, func(i, j int) bool {
-		return filteredInfos[i].loc < filteredInfos[j].loc
+	var filtered []*pathInfo
+	hasRoot := false
+	for _, info := range infos {
+		if strings.HasPrefix(info.loc, base+"/") || info.loc == base {
+			if info.loc == base {
+				hasRoot = true
+			}
+			filtered = append(filtered, info)
+		}
+	}
+	if !hasRoot {
+		filtered = append(filtered, &pathInfo{loc: base, lastmod: time.Now().UTC()})
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return filtered[i].loc < filtered[j].loc
 	})
 
+	return filtered
+}
+
+// shardsForHost splits infos into chunks that each respect the sitemaps.org
+// per-file limits (50,000 URLs / 50 MB).
+func (sg *sitemapGenerator) shardsForHost(infos []*pathInfo) [][]*pathInfo {
+	var shards [][]*pathInfo
+	var current []*pathInfo
+	currentSize := 0
+
+	for _, info := range infos {
+		entrySize := len(info.loc) + 64 // rough <url> element overhead
+		if len(current) > 0 && (len(current) >= maxURLsPerSitemap || currentSize+entrySize > maxSitemapBytes) {
+			shards = append(shards, current)
+			current = nil
+			currentSize = 0
+		}
+		current = append(current, info)
+		currentSize += entrySize
+	}
+	if len(current) > 0 {
+		shards = append(shards, current)
+	}
+
+	return shards
+}
+
+func (sg *sitemapGenerator) shardURL(host string, n int) string {
+	if sg.perHostShards {
+		return fmt.Sprintf("%s/%s/sitemap-%d.xml.gz", sg.shardPathPrefix, host, n)
+	}
+	return fmt.Sprintf("%s/sitemap-%d.xml.gz", sg.shardPathPrefix, n)
+}
+
+type sitemapImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+type sitemapVideo struct {
+	ContentLoc   string `xml:"video:content_loc,omitempty"`
+	ThumbnailLoc string `xml:"video:thumbnail_loc,omitempty"`
+}
+
+type sitemapNewsPublication struct {
+	Name     string `xml:"news:name"`
+	Language string `xml:"news:language"`
+}
+
+type sitemapNews struct {
+	Publication     sitemapNewsPublication `xml:"news:publication"`
+	PublicationDate string                 `xml:"news:publication_date"`
+	Title           string                 `xml:"news:title"`
+}
+
+type sitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+func (sg *sitemapGenerator) buildURLSetXML(infos []*pathInfo, base string) []byte {
 	type URL struct {
-		Loc      string  `xml:"loc"`
-		Lastmod  string  `xml:"lastmod"`
-		Priority float64 `xml:"priority"`
+		Loc        string             `xml:"loc"`
+		Lastmod    string             `xml:"lastmod"`
+		Changefreq string             `xml:"changefreq,omitempty"`
+		Priority   float64            `xml:"priority"`
+		Alternates []sitemapAlternate `xml:"xhtml:link,omitempty"`
+		Images     []sitemapImage     `xml:"image:image,omitempty"`
+		Videos     []sitemapVideo     `xml:"video:video,omitempty"`
+		News       *sitemapNews       `xml:"news:news,omitempty"`
 	}
 	type URLSet struct {
-		XMLName xml.Name `xml:"urlset"`
-		Xmlns   string   `xml:"xmlns,attr"`
-		URLs    []URL    `xml:"url"`
+		XMLName    xml.Name `xml:"urlset"`
+		Xmlns      string   `xml:"xmlns,attr"`
+		XmlnsImage string   `xml:"xmlns:image,attr"`
+		XmlnsVideo string   `xml:"xmlns:video,attr"`
+		XmlnsNews  string   `xml:"xmlns:news,attr"`
+		XmlnsXhtml string   `xml:"xmlns:xhtml,attr"`
+		URLs       []URL    `xml:"url"`
 	}
 
 	urlset := URLSet{
-		Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9",
+		Xmlns:      "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsImage: sitemapNSImage,
+		XmlnsVideo: sitemapNSVideo,
+		XmlnsNews:  sitemapNSNews,
+		XmlnsXhtml: sitemapNSXhtml,
 	}
 
-	now := time.Now().UTC()
-	lastmodStr := now.Format("2006-01-02T15:04:05Z")
+	defaultLastmod := time.Now().UTC().Format("2006-01-02T15:04:05Z")
 
-	for _, info := range filteredInfos {
+	for _, info := range infos {
 		priority := 0.8
 		if base != "" && info.loc == base {
 			priority = 1.0
 		}
-		urlset.URLs = append(urlset.URLs, URL{Loc: info.loc, Lastmod: lastmodStr, Priority: priority})
+
+		lastmodStr := defaultLastmod
+		if !info.lastmod.IsZero() {
+			lastmodStr = info.lastmod.Format("2006-01-02T15:04:05Z")
+		}
+
+		url := URL{
+			Loc:        info.loc,
+			Lastmod:    lastmodStr,
+			Changefreq: estimateChangefreq(info),
+			Priority:   priority,
+		}
+
+		langs := make([]string, 0, len(info.hreflangs))
+		for lang := range info.hreflangs {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			url.Alternates = append(url.Alternates, sitemapAlternate{
+				Rel:      "alternate",
+				Hreflang: lang,
+				Href:     info.hreflangs[lang],
+			})
+		}
+
+		imgs := make([]string, 0, len(info.images))
+		for img := range info.images {
+			imgs = append(imgs, img)
+		}
+		sort.Strings(imgs)
+		for _, img := range imgs {
+			url.Images = append(url.Images, sitemapImage{Loc: img})
+		}
+
+		for _, v := range info.videos {
+			url.Videos = append(url.Videos, sitemapVideo{ContentLoc: v.contentLoc, ThumbnailLoc: v.thumbnailLoc})
+		}
+
+		// news:news requires name/language/title; without all three the
+		// block is spec-invalid and Google News rejects it outright, so
+		// only emit it once scanAssets has found every required field.
+		if info.newsPublished != "" && info.newsName != "" && info.newsLanguage != "" && info.newsTitle != "" {
+			url.News = &sitemapNews{
+				Publication: sitemapNewsPublication{
+					Name:     info.newsName,
+					Language: info.newsLanguage,
+				},
+				PublicationDate: info.newsPublished,
+				Title:           info.newsTitle,
+			}
+		}
+
+		urlset.URLs = append(urlset.URLs, url)
 	}
 
 	output, err := xml.MarshalIndent(urlset, "", "  ")
@@ -340,13 +1539,588 @@ func (sg *sitemapGenerator) buildSitemapXML(req *http.Request) []byte {
 	return []byte(xml.Header + string(output))
 }
 
-func (sg *sitemapGenerator) buildRobotsTxt(req *http.Request) string {
-	scheme := req.Header.Get("X-Forwarded-Proto")
-	if scheme == "" {
-		scheme = req.URL.Scheme
+func (sg *sitemapGenerator) buildSitemapXML(req *http.Request) []byte {
+	if req == nil {
+		sg.mu.Lock()
+		infos := make([]*pathInfo, 0, len(sg.paths))
+		for _, info := range sg.paths {
+			infos = append(infos, info)
+		}
+		sg.mu.Unlock()
+
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].loc < infos[j].loc
+		})
+
+		return sg.buildURLSetXML(infos, "")
+	}
+
+	base := schemeOf(req) + "://" + req.Host
+	return sg.buildURLSetXML(sg.infosForHost(base), base)
+}
+
+// buildSitemapIndexXML renders a sitemap index listing one <sitemap> entry
+// per gzipped shard, as produced by shardsForHost.
+func (sg *sitemapGenerator) buildSitemapIndexXML(req *http.Request) []byte {
+	if req == nil {
+		return nil
+	}
+
+	host := req.Host
+	base := schemeOf(req) + "://" + host
+	shards := sg.shardsForHost(sg.infosForHost(base))
+
+	type sitemapRef struct {
+		Loc     string `xml:"loc"`
+		Lastmod string `xml:"lastmod"`
+	}
+	type sitemapIndex struct {
+		XMLName  xml.Name     `xml:"sitemapindex"`
+		Xmlns    string       `xml:"xmlns,attr"`
+		Sitemaps []sitemapRef `xml:"sitemap"`
+	}
+
+	lastmodStr := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	idx := sitemapIndex{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for n := range shards {
+		idx.Sitemaps = append(idx.Sitemaps, sitemapRef{
+			Loc:     base + sg.shardURL(host, n+1),
+			Lastmod: lastmodStr,
+		})
 	}
+
+	output, err := xml.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return nil
+	}
+
+	return []byte(xml.Header + string(output))
+}
+
+// serveShard writes a single gzipped sitemap shard selected by the index
+// (and, in per-host mode, the host) captured by sg.shardRe.
+func (sg *sitemapGenerator) serveShard(rw http.ResponseWriter, req *http.Request, m []string) {
 	host := req.Host
-	sitemapURL := scheme + "://" + host + sg.sitemapPath
+	idxStr := m[1]
+	if sg.perHostShards {
+		host = m[1]
+		idxStr = m[2]
+	}
+
+	idx, err := strconv.Atoi(idxStr)
+	if err != nil || idx < 1 {
+		http.NotFound(rw, req)
+		return
+	}
+
+	base := schemeOf(req) + "://" + host
+	shards := sg.shardsForHost(sg.infosForHost(base))
+	if idx > len(shards) {
+		http.NotFound(rw, req)
+		return
+	}
+
+	xmlContent := sg.buildURLSetXML(shards[idx-1], base)
+	if xmlContent == nil {
+		http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	xmlContent = sg.maybeMinify("application/xml", xmlContent)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(xmlContent); err != nil {
+		http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := gz.Close(); err != nil {
+		http.Error(rw, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/xml")
+	rw.Header().Set("Content-Encoding", "gzip")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(buf.Bytes())
+}
+
+// prefixIgnoreRe recognizes Ignore regexes that map cleanly onto a literal
+// path prefix, e.g. `^/_next/*`, so they can also be emitted as Disallow
+// rules without operators having to repeat themselves.
+var prefixIgnoreRe = regexp.MustCompile(`^\^(/[A-Za-z0-9_\-./]*?)\*?$`)
+
+func (sg *sitemapGenerator) autoDisallowPaths() []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, pattern := range sg.ignorePatterns {
+		m := prefixIgnoreRe.FindStringSubmatch(pattern)
+		if m == nil || m[1] == "" {
+			continue
+		}
+		if _, ok := seen[m[1]]; ok {
+			continue
+		}
+		seen[m[1]] = struct{}{}
+		out = append(out, m[1])
+	}
+	sort.Strings(out)
+	return out
+}
+
+// readFragments concatenates every file in a robots.txt.d/ directory, in
+// name order, letting operators drop in per-app rules without touching the
+// middleware's own config.
+func (sg *sitemapGenerator) readFragments() string {
+	entries, err := os.ReadDir(sg.robots.FragmentsDir)
+	if err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(sg.robots.FragmentsDir, name))
+		if err != nil {
+			continue
+		}
+		b.Write(data)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (sg *sitemapGenerator) shouldMinify(contentType string) bool {
+	ct := baseContentType(contentType)
+	for _, t := range sg.minify.ContentTypes {
+		if strings.EqualFold(t, ct) {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeMinify runs b through the configured minifier when enabled and
+// contentType is opted in, falling back to the original bytes on error.
+func (sg *sitemapGenerator) maybeMinify(contentType string, b []byte) []byte {
+	if sg.minifier == nil || !sg.shouldMinify(contentType) {
+		return b
+	}
+	minified, err := sg.minifier.Minify(contentType, b)
+	if err != nil {
+		return b
+	}
+	return minified
+}
+
+func (sg *sitemapGenerator) buildRobotsTxt(req *http.Request) string {
+	var b strings.Builder
+
+	groups := sg.robots.Groups
+	if len(groups) == 0 {
+		groups = []RobotsGroup{{UserAgents: []string{"*"}}}
+	}
+
+	for _, g := range groups {
+		agents := g.UserAgents
+		if len(agents) == 0 {
+			agents = []string{"*"}
+		}
+		for _, ua := range agents {
+			fmt.Fprintf(&b, "User-agent: %s\n", ua)
+		}
+		for _, allow := range g.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", allow)
+		}
+
+		disallow := g.Disallow
+		if sg.robots.AutoDisallow {
+			disallow = append(append([]string{}, disallow...), sg.autoDisallowPaths()...)
+		}
+		for _, d := range disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", d)
+		}
+
+		if g.CrawlDelay > 0 {
+			fmt.Fprintf(&b, "Crawl-delay: %d\n", g.CrawlDelay)
+		}
+		if g.Host != "" {
+			fmt.Fprintf(&b, "Host: %s\n", g.Host)
+		}
+		b.WriteString("\n")
+	}
+
+	sitemapURLs := sg.robots.SitemapURLs
+	if len(sitemapURLs) == 0 {
+		sitemapURLs = []string{schemeOf(req) + "://" + req.Host + sg.sitemapPath}
+	}
+	for _, s := range sitemapURLs {
+		fmt.Fprintf(&b, "Sitemap: %s\n", s)
+	}
+
+	if sg.robots.FragmentsDir != "" {
+		if frag := sg.readFragments(); frag != "" {
+			b.WriteString("\n")
+			b.WriteString(frag)
+		}
+	}
+
+	return b.String()
+}
+
+// discardResponseWriter satisfies http.ResponseWriter for requests the
+// crawler synthesizes itself; the response never reaches a real connection.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *discardResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// fetch drives a synthesized request straight into sg.next, the same way
+// ServeHTTP does for real traffic, and returns the (decompressed) body. It
+// never touches the network; rawURL only supplies the Host/path sg.next
+// needs to route the request.
+func (sg *sitemapGenerator) fetch(ctx context.Context, rawURL string) (status int, contentType string, body []byte, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, "", nil, err
+	}
 
-	return fmt.Sprintf("User-agent: *\nSitemap: %s\n", sitemapURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	req.Host = u.Host
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	dw := &discardResponseWriter{header: make(http.Header)}
+	sg.next.ServeHTTP(dw, req)
+
+	status = dw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType = dw.header.Get("Content-Type")
+	bodyBytes := dw.body.Bytes()
+
+	if strings.EqualFold(dw.header.Get("Content-Encoding"), "gzip") {
+		reader, gzErr := gzip.NewReader(bytes.NewReader(bodyBytes))
+		if gzErr == nil {
+			defer reader.Close()
+			if decompressed, readErr := io.ReadAll(reader); readErr == nil {
+				bodyBytes = decompressed
+			}
+		}
+	}
+
+	return status, contentType, bodyBytes, nil
+}
+
+// crawlTask is one BFS node: a URL discovered at a given link depth from its
+// seed.
+type crawlTask struct {
+	url   string
+	depth int
+}
+
+// robotsRule is a single Allow/Disallow path-prefix directive.
+type robotsRule struct {
+	path  string
+	allow bool
+}
+
+// robotsGroupRules is one User-agent block's rules.
+type robotsGroupRules struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// parseRobotsTxt parses a robots.txt document into its User-agent groups.
+// It implements the common subset of the spec this crawler needs - grouping
+// by one or more User-agent lines and collecting the Allow/Disallow prefixes
+// that follow them - and ignores Crawl-delay/Sitemap/Host/comments. This
+// replaces github.com/temoto/robotstxt: Traefik loads plugins through Yaegi,
+// which can only interpret a plugin's own vendored source tree, and parsing
+// robots.txt doesn't need a full third-party dependency.
+func parseRobotsTxt(body []byte) []robotsGroupRules {
+	var groups []robotsGroupRules
+	var current *robotsGroupRules
+	sawRule := false
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			// A User-agent line starts a new group unless it directly
+			// follows another User-agent line in the same group (the usual
+			// way to list several agents under one set of rules).
+			if current == nil || sawRule {
+				groups = append(groups, robotsGroupRules{})
+				current = &groups[len(groups)-1]
+				sawRule = false
+			}
+			current.agents = append(current.agents, value)
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{path: value, allow: field == "allow"})
+			sawRule = true
+		}
+	}
+	return groups
+}
+
+// groupForAgent returns the most specific group matching agent - an exact,
+// case-insensitive User-agent match if there is one, else the "*" group -
+// or nil if robots.txt declares neither.
+func groupForAgent(groups []robotsGroupRules, agent string) *robotsGroupRules {
+	var wildcard *robotsGroupRules
+	for i := range groups {
+		for _, a := range groups[i].agents {
+			if strings.EqualFold(a, agent) {
+				return &groups[i]
+			}
+			if a == "*" {
+				wildcard = &groups[i]
+			}
+		}
+	}
+	return wildcard
+}
+
+// allowed reports whether path is allowed by g, using the standard
+// longest-matching-prefix rule (Allow wins ties). A nil group, or a path
+// matched by no rule, is allowed.
+func (g *robotsGroupRules) allowed(path string) bool {
+	if g == nil {
+		return true
+	}
+
+	bestLen := -1
+	bestAllow := true
+	for _, r := range g.rules {
+		if r.path == "" || !strings.HasPrefix(path, r.path) {
+			continue
+		}
+		if len(r.path) > bestLen || (len(r.path) == bestLen && r.allow) {
+			bestLen = len(r.path)
+			bestAllow = r.allow
+		}
+	}
+	return bestAllow
+}
+
+// robotsCache memoizes each host's robots rules, built in-process from
+// sg.buildRobotsTxt - not fetched over HTTP. /robots.txt is synthesized by sg
+// itself (ServeHTTP intercepts sg.robotsPath and never forwards it to
+// sg.next), so fetching it via sg.fetch, which calls sg.next.ServeHTTP,
+// would hit the upstream origin - which has no such route - and silently
+// fall back to "no rules" on every real deployment.
+type robotsCache struct {
+	mu   sync.Mutex
+	data map[string][]robotsGroupRules
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{data: make(map[string][]robotsGroupRules)}
+}
+
+func (rc *robotsCache) groupFor(sg *sitemapGenerator, u *url.URL) *robotsGroupRules {
+	rc.mu.Lock()
+	groups, ok := rc.data[u.Host]
+	rc.mu.Unlock()
+	if !ok {
+		scheme := u.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		req := &http.Request{Host: u.Host, URL: &url.URL{Scheme: scheme}, Header: make(http.Header)}
+		groups = parseRobotsTxt([]byte(sg.buildRobotsTxt(req)))
+
+		rc.mu.Lock()
+		rc.data[u.Host] = groups
+		rc.mu.Unlock()
+	}
+	return groupForAgent(groups, "*")
+}
+
+// crawlOne fetches a single crawl task through sg.next, records it exactly
+// as a real request would, and enqueues any links to allow-listed hosts it
+// discovers in the returned HTML.
+func (sg *sitemapGenerator) crawlOne(ctx context.Context, task crawlTask, hosts map[string]struct{}, maxDepth int, robots *robotsCache, enqueue func(crawlTask)) {
+	u, err := url.Parse(task.url)
+	if err != nil || u.Host == "" {
+		return
+	}
+	if _, allowed := hosts[u.Host]; !allowed {
+		return
+	}
+	for _, re := range sg.ignores {
+		if re.MatchString(u.Path) {
+			return
+		}
+	}
+	if group := robots.groupFor(sg, u); !group.allowed(u.Path) {
+		return
+	}
+
+	status, contentType, body, err := sg.fetch(ctx, task.url)
+	if err != nil || status != http.StatusOK {
+		return
+	}
+
+	fullURL := u.Scheme + "://" + u.Host + strings.TrimSuffix(u.Path, "/")
+	info, isNew := sg.record(fullURL, contentType, string(body))
+	if sg.store != nil {
+		if sg.devMode {
+			sg.persist()
+		} else {
+			sg.store.Record(ctx, fullURL, info)
+		}
+	}
+	if sg.devMode && isNew {
+		sg.publishSSEEvent(fullURL, info.lastmod)
+	}
+
+	if task.depth >= maxDepth || !strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+		return
+	}
+	for _, m := range anchorHrefRe.FindAllStringSubmatch(string(body), -1) {
+		href, err := url.Parse(m[1])
+		if err != nil {
+			continue
+		}
+		resolved := u.ResolveReference(href)
+		if _, allowed := hosts[resolved.Host]; !allowed {
+			continue
+		}
+		resolved.Fragment = ""
+		enqueue(crawlTask{url: resolved.String(), depth: task.depth + 1})
+	}
+}
+
+// crawlSeeds runs an in-process BFS from config.SeedURLs through sg.next, so
+// a freshly-deployed site has a populated sitemap before any real traffic
+// arrives. It runs in the background; New returns without waiting for it.
+func (sg *sitemapGenerator) crawlSeeds(ctx context.Context, config *Config) {
+	hosts := make(map[string]struct{}, len(config.SeedHosts))
+	for _, h := range config.SeedHosts {
+		hosts[h] = struct{}{}
+	}
+	if len(hosts) == 0 {
+		for _, seed := range config.SeedURLs {
+			if u, err := url.Parse(seed); err == nil && u.Host != "" {
+				hosts[u.Host] = struct{}{}
+			}
+		}
+	}
+
+	concurrency := config.SeedConcurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	maxDepth := config.SeedMaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 3
+	}
+	maxURLs := config.SeedMaxURLs
+	if maxURLs <= 0 {
+		maxURLs = 5000
+	}
+
+	var throttle <-chan time.Time
+	if config.SeedRatePerSec > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / config.SeedRatePerSec))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	var (
+		visitedMu sync.Mutex
+		visited   = make(map[string]struct{}, maxURLs)
+		robots    = newRobotsCache()
+		tasks     = make(chan crawlTask, maxURLs)
+		inflight  sync.WaitGroup
+	)
+
+	// enqueue is called both from the seed loop below and from crawlOne
+	// (running on a worker goroutine), so a task is sent on its own
+	// goroutine rather than directly on tasks - that worker may itself be
+	// the only reader and would deadlock sending into a full channel.
+	enqueue := func(task crawlTask) {
+		if task.depth > maxDepth {
+			return
+		}
+		visitedMu.Lock()
+		if _, seenBefore := visited[task.url]; seenBefore || len(visited) >= maxURLs {
+			visitedMu.Unlock()
+			return
+		}
+		visited[task.url] = struct{}{}
+		visitedMu.Unlock()
+
+		inflight.Add(1)
+		go func() { tasks <- task }()
+	}
+
+	for _, seed := range config.SeedURLs {
+		enqueue(crawlTask{url: seed, depth: 0})
+	}
+
+	go func() {
+		inflight.Wait()
+		close(tasks)
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for task := range tasks {
+				if throttle != nil {
+					<-throttle
+				}
+				sg.crawlOne(ctx, task, hosts, maxDepth, robots, enqueue)
+				inflight.Done()
+			}
+		}()
+	}
+	workers.Wait()
 }
@@ -0,0 +1,121 @@
+package seo
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestJSONPathStoreRoundTrip guards against the store silently dropping the
+// per-URL metadata (images, videos, hreflang, news fields) chunk0-2 added -
+// Save/Load used to only round-trip a lastmod timestamp.
+func TestJSONPathStoreRoundTrip(t *testing.T) {
+	store := &jsonPathStore{path: filepath.Join(t.TempDir(), "paths.json")}
+
+	want := &pathInfo{
+		loc:         "https://example.com/",
+		firstSeen:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		lastmod:     time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		updateCount: 3,
+		images: map[string]struct{}{
+			"https://example.com/hero.jpg": {},
+		},
+		videos: []videoAsset{
+			{contentLoc: "https://example.com/clip.mp4", thumbnailLoc: "https://example.com/clip.jpg"},
+		},
+		hreflangs: map[string]string{
+			"en": "https://example.com/",
+			"fr": "https://example.com/fr/",
+		},
+		newsPublished: "2026-01-01T00:00:00Z",
+		newsName:      "Example News",
+		newsLanguage:  "en",
+		newsTitle:     "Example headline",
+	}
+
+	ctx := context.Background()
+	paths := map[string]*pathInfo{want.loc: want}
+	if err := store.Save(ctx, paths); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	got, ok := loaded[want.loc]
+	if !ok {
+		t.Fatalf("Load did not return %q", want.loc)
+	}
+
+	if got.loc != want.loc || !got.firstSeen.Equal(want.firstSeen) || !got.lastmod.Equal(want.lastmod) {
+		t.Errorf("loc/firstSeen/lastmod mismatch: got %+v, want %+v", got, want)
+	}
+	if got.updateCount != want.updateCount {
+		t.Errorf("updateCount = %d, want %d", got.updateCount, want.updateCount)
+	}
+	if _, ok := got.images["https://example.com/hero.jpg"]; !ok {
+		t.Errorf("images lost on round-trip: got %v", got.images)
+	}
+	if len(got.videos) != 1 || got.videos[0].contentLoc != want.videos[0].contentLoc {
+		t.Errorf("videos lost on round-trip: got %v", got.videos)
+	}
+	if got.hreflangs["fr"] != "https://example.com/fr/" {
+		t.Errorf("hreflangs lost on round-trip: got %v", got.hreflangs)
+	}
+	if got.newsPublished != want.newsPublished || got.newsName != want.newsName ||
+		got.newsLanguage != want.newsLanguage || got.newsTitle != want.newsTitle {
+		t.Errorf("news fields lost on round-trip: got %+v", got)
+	}
+}
+
+// TestShardsForHostCount verifies the 50,000-URL-per-shard limit from
+// sitemaps.org is respected.
+func TestShardsForHostCount(t *testing.T) {
+	sg := &sitemapGenerator{}
+
+	infos := make([]*pathInfo, maxURLsPerSitemap+1)
+	for i := range infos {
+		infos[i] = &pathInfo{loc: "https://example.com/p"}
+	}
+
+	shards := sg.shardsForHost(infos)
+	if len(shards) != 2 {
+		t.Fatalf("got %d shards, want 2", len(shards))
+	}
+	if len(shards[0]) != maxURLsPerSitemap {
+		t.Errorf("first shard has %d URLs, want %d", len(shards[0]), maxURLsPerSitemap)
+	}
+	if len(shards[1]) != 1 {
+		t.Errorf("second shard has %d URLs, want 1", len(shards[1]))
+	}
+}
+
+// TestShardsForHostSize verifies shards are also split once the 50MB
+// sitemaps.org size limit would be exceeded, even with far fewer than
+// maxURLsPerSitemap entries.
+func TestShardsForHostSize(t *testing.T) {
+	sg := &sitemapGenerator{}
+
+	// Each entry is ~20MB (len(loc)+64 overhead), so a third entry pushes
+	// the running total past maxSitemapBytes (50MB) and forces a new shard.
+	bigLoc := "https://example.com/" + string(make([]byte, 20_000_000-64))
+	infos := []*pathInfo{
+		{loc: bigLoc},
+		{loc: bigLoc},
+		{loc: bigLoc},
+	}
+
+	shards := sg.shardsForHost(infos)
+	if len(shards) != 2 {
+		t.Fatalf("got %d shards, want 2", len(shards))
+	}
+	if len(shards[0]) != 2 {
+		t.Errorf("first shard has %d URLs, want 2", len(shards[0]))
+	}
+	if len(shards[1]) != 1 {
+		t.Errorf("second shard has %d URLs, want 1", len(shards[1]))
+	}
+}
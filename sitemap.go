@@ -1,13 +1,21 @@
 package traefik_sitemap_generator
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +25,11 @@ type Config struct {
 	OutputFile  string   `json:"outputFile,omitempty"`
 	SitemapPath string   `json:"sitemapPath,omitempty"`
 	Ignore      []string `json:"ignore,omitempty"`
+	StoreType   string   `json:"storeType,omitempty"` // "", "json", "bolt", "redis"
+	StorePath   string   `json:"storePath,omitempty"` // file path for the json/bolt stores
+	RedisAddr   string   `json:"redisAddr,omitempty"`
+	RedisKey    string   `json:"redisKey,omitempty"`
+	PathTTL     string   `json:"pathTTL,omitempty"` // e.g. "720h"; empty disables pruning
 }
 
 func CreateConfig() *Config {
@@ -25,13 +38,392 @@ func CreateConfig() *Config {
 	}
 }
 
+// PathStore persists the set of recorded URLs across restarts so a freshly
+// started instance doesn't serve an empty sitemap until traffic rebuilds it.
+type PathStore interface {
+	Load(ctx context.Context) (map[string]time.Time, error)
+	Save(ctx context.Context, paths map[string]time.Time) error
+	Record(ctx context.Context, url string, seen time.Time) error
+}
+
+func newPathStore(config *Config) (PathStore, error) {
+	switch config.StoreType {
+	case "json":
+		if config.StorePath == "" {
+			return nil, fmt.Errorf("storePath is required for the json store")
+		}
+		return &jsonPathStore{path: config.StorePath}, nil
+	case "bolt":
+		if config.StorePath == "" {
+			return nil, fmt.Errorf("storePath is required for the bolt store")
+		}
+		return newBoltPathStore(config.StorePath)
+	case "redis":
+		if config.RedisAddr == "" {
+			return nil, fmt.Errorf("redisAddr is required for the redis store")
+		}
+		key := config.RedisKey
+		if key == "" {
+			key = "sitemap:paths"
+		}
+		return &redisPathStore{addr: config.RedisAddr, key: key}, nil
+	default:
+		return nil, fmt.Errorf("unknown storeType %q", config.StoreType)
+	}
+}
+
+type jsonPathStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *jsonPathStore) Load(ctx context.Context) (map[string]time.Time, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]time.Time{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]time.Time)
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+func (s *jsonPathStore) Save(ctx context.Context, paths map[string]time.Time) error {
+	data, err := json.Marshal(paths)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *jsonPathStore) Record(ctx context.Context, url string, seen time.Time) error {
+	// The on-disk JSON file is only ever rewritten wholesale by Save on the
+	// periodic tick; per-request writes would thrash the filesystem.
+	return nil
+}
+
+// boltPathStoreEntry is the unit persisted to the append-only log file:
+// Record appends one of these per call, and Load replays the log keeping the
+// last entry seen per URL.
+type boltPathStoreEntry struct {
+	URL  string    `json:"url"`
+	Seen time.Time `json:"seen"`
+}
+
+// boltPathStore persists paths as a length-prefixed-JSON append-only log
+// file, using only the standard library. It used to be backed by
+// go.etcd.io/bbolt, but Traefik loads plugins through Yaegi, which can only
+// interpret a plugin's own vendored source - and bbolt's mmap/unsafe-based
+// file access in particular doesn't interpret at all. The append-only log
+// keeps the property that made "bolt" worth choosing over "json" in the
+// first place: Record doesn't rewrite the whole file on every request.
+// Save, run on the periodic tick, compacts the log down to one entry per URL.
+type boltPathStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func newBoltPathStore(path string) (*boltPathStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt store: %v", err)
+	}
+	return &boltPathStore{path: path, f: f}, nil
+}
+
+func (s *boltPathStore) Load(ctx context.Context) (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	defer s.f.Seek(0, io.SeekEnd)
+
+	paths := make(map[string]time.Time)
+	r := bufio.NewReader(s.f)
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			break // EOF, or a short trailing write from a crash mid-append.
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+		var entry boltPathStoreEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			continue
+		}
+		paths[entry.URL] = entry.Seen
+	}
+	return paths, nil
+}
+
+func (s *boltPathStore) appendEntry(url string, seen time.Time) error {
+	data, err := json.Marshal(boltPathStoreEntry{URL: url, Seen: seen})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := binary.Write(s.f, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = s.f.Write(data)
+	return err
+}
+
+func (s *boltPathStore) Record(ctx context.Context, url string, seen time.Time) error {
+	return s.appendEntry(url, seen)
+}
+
+// Save compacts the log down to exactly one entry per URL - the only point
+// the whole file is rewritten - via the same write-tmp-then-rename pattern
+// jsonPathStore uses.
+func (s *boltPathStore) Save(ctx context.Context, paths map[string]time.Time) error {
+	tmp := s.path + ".tmp"
+	tmpFile, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	for url, seen := range paths {
+		data, err := json.Marshal(boltPathStoreEntry{URL: url, Seen: seen})
+		if err != nil {
+			tmpFile.Close()
+			return err
+		}
+		if err := binary.Write(tmpFile, binary.BigEndian, uint32(len(data))); err != nil {
+			tmpFile.Close()
+			return err
+		}
+		if _, err := tmpFile.Write(data); err != nil {
+			tmpFile.Close()
+			return err
+		}
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.f = f
+	return nil
+}
+
+// respValue is a parsed RESP (Redis Serialization Protocol) reply: exactly
+// the subset (simple strings, integers, bulk strings, arrays) needed to
+// speak HGETALL/HSET.
+type respValue struct {
+	str   string
+	num   int64
+	arr   []*respValue
+	isNil bool
+}
+
+func readRESP(r *bufio.Reader) (*respValue, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply line")
+	}
+
+	switch line[0] {
+	case '+':
+		return &respValue{str: line[1:]}, nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &respValue{num: n}, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return &respValue{isNil: true}, nil
+		}
+		data := make([]byte, n+2) // payload plus the trailing CRLF
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return &respValue{str: string(data[:n])}, nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return &respValue{isNil: true}, nil
+		}
+		arr := make([]*respValue, n)
+		for i := range arr {
+			v, err := readRESP(r)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+		return &respValue{arr: arr}, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+	}
+}
+
+// redisPathStore speaks just enough RESP over a plain net.Conn to drive
+// HGETALL/HSET against a hash, using only the standard library. It used to
+// wrap github.com/redis/go-redis/v9, but Yaegi - the interpreter Traefik
+// loads plugins with - can only interpret a plugin's own vendored source
+// tree, and a client this small doesn't need a full dependency to replace.
+type redisPathStore struct {
+	addr string
+	key  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (s *redisPathStore) connect() (net.Conn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn, nil
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return conn, nil
+}
+
+// do sends a RESP command array and returns the parsed reply, retrying once
+// against a fresh connection if the cached one turns out to be dead (e.g.
+// closed by the server after sitting idle).
+func (s *redisPathStore) do(args ...string) (*respValue, error) {
+	conn, err := s.connect()
+	if err != nil {
+		return nil, err
+	}
+	val, err := s.doOnce(conn, args)
+	if err == nil {
+		return val, nil
+	}
+
+	s.mu.Lock()
+	if s.conn == conn {
+		conn.Close()
+		s.conn = nil
+	}
+	s.mu.Unlock()
+
+	conn, err = s.connect()
+	if err != nil {
+		return nil, err
+	}
+	return s.doOnce(conn, args)
+}
+
+func (s *redisPathStore) doOnce(conn net.Conn, args []string) (*respValue, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&buf, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return readRESP(bufio.NewReader(conn))
+}
+
+func (s *redisPathStore) Load(ctx context.Context) (map[string]time.Time, error) {
+	reply, err := s.do("HGETALL", s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]time.Time)
+	for i := 0; i+1 < len(reply.arr); i += 2 {
+		seen, err := time.Parse(time.RFC3339, reply.arr[i+1].str)
+		if err != nil {
+			continue
+		}
+		paths[reply.arr[i].str] = seen
+	}
+	return paths, nil
+}
+
+func (s *redisPathStore) Save(ctx context.Context, paths map[string]time.Time) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, 2+2*len(paths))
+	args = append(args, "HSET", s.key)
+	for url, seen := range paths {
+		args = append(args, url, seen.Format(time.RFC3339))
+	}
+	_, err := s.do(args...)
+	return err
+}
+
+func (s *redisPathStore) Record(ctx context.Context, url string, seen time.Time) error {
+	_, err := s.do("HSET", s.key, url, seen.Format(time.RFC3339))
+	return err
+}
+
 type sitemapGenerator struct {
 	next        http.Handler
 	name        string
 	outputFile  string
 	sitemapPath string
 	ignores     []*regexp.Regexp
-	paths       map[string]struct{}
+	paths       map[string]time.Time
+	store       PathStore
+	pathTTL     time.Duration
 	mu          sync.Mutex
 }
 
@@ -52,13 +444,39 @@ func New(ctx context.Context, next http.Handler, config *Config, name string) (h
 		ignores = append(ignores, re)
 	}
 
+	var pathTTL time.Duration
+	if config.PathTTL != "" {
+		parsed, err := time.ParseDuration(config.PathTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pathTTL %s: %v", config.PathTTL, err)
+		}
+		pathTTL = parsed
+	}
+
 	sg := &sitemapGenerator{
 		next:        next,
 		name:        name,
 		outputFile:  config.OutputFile,
 		sitemapPath: config.SitemapPath,
 		ignores:     ignores,
-		paths:       make(map[string]struct{}),
+		paths:       make(map[string]time.Time),
+		pathTTL:     pathTTL,
+	}
+
+	if config.StoreType != "" {
+		store, err := newPathStore(config)
+		if err != nil {
+			return nil, err
+		}
+		sg.store = store
+
+		loaded, err := store.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading persisted paths: %v", err)
+		}
+		for url, seen := range loaded {
+			sg.paths[url] = seen
+		}
 	}
 
 	go sg.generateSitemapPeriodically()
@@ -96,9 +514,15 @@ func (sg *sitemapGenerator) ServeHTTP(rw http.ResponseWriter, req *http.Request)
 		}
 		host := req.Host
 		fullURL := scheme + "://" + host + strings.TrimSuffix(path, "/")
+		seen := time.Now().UTC()
+
 		sg.mu.Lock()
-		sg.paths[fullURL] = struct{}{}
+		sg.paths[fullURL] = seen
 		sg.mu.Unlock()
+
+		if sg.store != nil {
+			sg.store.Record(req.Context(), fullURL, seen)
+		}
 	}
 
 	sg.next.ServeHTTP(rw, req)
@@ -110,10 +534,45 @@ func (sg *sitemapGenerator) generateSitemapPeriodically() {
 
 	for {
 		<-ticker.C
+		sg.pruneStalePaths()
 		sg.generateSitemap()
+		sg.persist()
 	}
 }
 
+// pruneStalePaths drops URLs that haven't been seen in pathTTL, so routes
+// that disappear from the upstream site eventually drop out of the sitemap.
+func (sg *sitemapGenerator) pruneStalePaths() {
+	if sg.pathTTL <= 0 {
+		return
+	}
+
+	cutoff := time.Now().UTC().Add(-sg.pathTTL)
+
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+	for url, seen := range sg.paths {
+		if seen.Before(cutoff) {
+			delete(sg.paths, url)
+		}
+	}
+}
+
+func (sg *sitemapGenerator) persist() {
+	if sg.store == nil {
+		return
+	}
+
+	sg.mu.Lock()
+	snapshot := make(map[string]time.Time, len(sg.paths))
+	for url, seen := range sg.paths {
+		snapshot[url] = seen
+	}
+	sg.mu.Unlock()
+
+	sg.store.Save(context.Background(), snapshot)
+}
+
 func (sg *sitemapGenerator) generateSitemap() {
 	xmlContent := sg.buildSitemapXML(nil)
 	if xmlContent == nil {
@@ -125,7 +584,11 @@ func (sg *sitemapGenerator) generateSitemap() {
 		return
 	}
 
-	if err := os.WriteFile(sg.outputFile, xmlContent, 0644); err != nil {
+	tmp := sg.outputFile + ".tmp"
+	if err := os.WriteFile(tmp, xmlContent, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, sg.outputFile); err != nil {
 		return
 	}
 }